@@ -0,0 +1,205 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/mattermost/mattermost-server/v5/mlog"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// maxBatchBytes caps the summed JSON size of the messages in a single syncBatch before it's split,
+	// keeping individual RemoteClusterMsg round trips bounded regardless of channel activity.
+	maxBatchBytes = 512 * 1024
+
+	// maxBatchCount caps how many syncMsg values a single syncBatch may carry, independent of
+	// size, so one slow/large remote doesn't hold an unbounded number of posts in flight.
+	maxBatchCount = 100
+)
+
+// syncBatch bundles many syncMsg values bound for the same RemoteCluster into a single
+// RemoteClusterMsg payload, trading one round trip per post for one round trip per batch.
+type syncBatch struct {
+	Messages []syncMsg `json:"messages"`
+}
+
+func (sb syncBatch) ToJSON() ([]byte, error) {
+	return json.Marshal(sb)
+}
+
+// syncBatchEnvelope is the wire payload carried by a RemoteClusterMsg. Codec is readable without
+// decoding Payload so the receiver knows whether to decompress before unmarshalling it as a
+// syncBatch.
+type syncBatchEnvelope struct {
+	Codec   string `json:"codec"` // "", "gzip" or "zstd"
+	Payload []byte `json:"payload"`
+}
+
+// batchSyncMessages packs msgs into one or more syncBatch values no longer than maxCount, whose
+// messages' individual JSON encodings sum to no more than maxBytes (the batch's own JSON wrapper
+// and separators are not counted), preserving input order so per-channel delivery order is preserved. A
+// message whose own JSON encoding already exceeds maxBytes is placed alone in its own batch rather
+// than being dropped or blocking everything behind it.
+func batchSyncMessages(msgs []syncMsg, maxBytes int, maxCount int) ([]syncBatch, error) {
+	var batches []syncBatch
+	var current []syncMsg
+	var currentBytes int
+
+	flush := func() {
+		if len(current) > 0 {
+			batches = append(batches, syncBatch{Messages: current})
+			current = nil
+			currentBytes = 0
+		}
+	}
+
+	for _, m := range msgs {
+		encoded, err := m.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(current) > 0 && (currentBytes+len(encoded) > maxBytes || len(current) >= maxCount) {
+			flush()
+		}
+
+		current = append(current, m)
+		currentBytes += len(encoded)
+	}
+	flush()
+
+	return batches, nil
+}
+
+// batchesForRemote converts msgs into wire-ready envelope payloads for rc, compressing each with
+// the codec rc.SyncCompressionCodec negotiated for it ("", "gzip", or "zstd"), and records
+// size/compression metrics.
+func (scs *Service) batchesForRemote(msgs []syncMsg, rc *model.RemoteCluster) ([][]byte, error) {
+	batches, err := batchSyncMessages(msgs, maxBatchBytes, maxBatchCount)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([][]byte, 0, len(batches))
+
+	for _, b := range batches {
+		raw, err := b.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		env := syncBatchEnvelope{Payload: raw}
+
+		codec := rc.SyncCompressionCodec
+		if compress, ok := batchCodecs[codec]; ok && codec != "" {
+			compressed, cerr := compress(raw)
+			if cerr != nil {
+				scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not compress shared channel sync batch; sending uncompressed",
+					mlog.String("remote_id", rc.RemoteId),
+					mlog.String("codec", codec),
+					mlog.Err(cerr),
+				)
+			} else {
+				env.Codec = codec
+				env.Payload = compressed
+			}
+		} else if codec != "" {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Unknown shared channel sync compression codec; sending uncompressed",
+				mlog.String("remote_id", rc.RemoteId),
+				mlog.String("codec", codec),
+			)
+		}
+
+		scs.recordBatch(rc.RemoteId, len(raw), len(env.Payload))
+
+		encoded, err := json.Marshal(env)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, encoded)
+	}
+
+	return envelopes, nil
+}
+
+// batchCodecs maps a negotiated codec name to its compressor. Both sides of a shared channel
+// connection agree on rc.SyncCompressionCodec out of band (remote cluster capability exchange);
+// this is just the set this server knows how to produce.
+var batchCodecs = map[string]func([]byte) ([]byte, error){
+	"gzip": gzipCompress,
+	"zstd": zstdCompress,
+}
+
+func gzipCompress(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func zstdCompress(raw []byte) ([]byte, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(raw, nil), nil
+}
+
+// batchMetrics accumulates per-remote counters for average batch size and compression ratio so
+// operators can judge whether maxBatchBytes/maxBatchCount need tuning.
+type batchMetrics struct {
+	batchCount      int64
+	uncompressedSum int64
+	sentSum         int64
+}
+
+// recordBatch updates the running totals for remoteId from one envelope's uncompressed and
+// on-the-wire sizes.
+func (scs *Service) recordBatch(remoteId string, uncompressedBytes int, sentBytes int) {
+	scs.batchMetricsMux.Lock()
+	defer scs.batchMetricsMux.Unlock()
+
+	if scs.batchMetricsByRemote == nil {
+		scs.batchMetricsByRemote = make(map[string]*batchMetrics)
+	}
+	bm, ok := scs.batchMetricsByRemote[remoteId]
+	if !ok {
+		bm = &batchMetrics{}
+		scs.batchMetricsByRemote[remoteId] = bm
+	}
+
+	atomic.AddInt64(&bm.batchCount, 1)
+	atomic.AddInt64(&bm.uncompressedSum, int64(uncompressedBytes))
+	atomic.AddInt64(&bm.sentSum, int64(sentBytes))
+}
+
+// BatchMetrics returns the average uncompressed batch size in bytes and the overall compression
+// ratio (sent/uncompressed) observed for remoteId, or zero values if no batches have been sent yet.
+func (scs *Service) BatchMetrics(remoteId string) (avgBatchBytes float64, compressionRatio float64) {
+	scs.batchMetricsMux.Lock()
+	bm, ok := scs.batchMetricsByRemote[remoteId]
+	scs.batchMetricsMux.Unlock()
+	if !ok || bm.batchCount == 0 {
+		return 0, 0
+	}
+
+	avgBatchBytes = float64(bm.uncompressedSum) / float64(bm.batchCount)
+	if bm.uncompressedSum > 0 {
+		compressionRatio = float64(bm.sentSum) / float64(bm.uncompressedSum)
+	}
+	return avgBatchBytes, compressionRatio
+}
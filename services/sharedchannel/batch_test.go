@@ -0,0 +1,84 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSyncMsg(t *testing.T, postId string, messageLen int) syncMsg {
+	t.Helper()
+	return syncMsg{
+		ChannelId: "channel1",
+		PostId:    postId,
+		Post:      &model.Post{Id: postId, Message: strings.Repeat("x", messageLen)},
+	}
+}
+
+func TestBatchSyncMessagesSplitsOnCount(t *testing.T) {
+	msgs := make([]syncMsg, 5)
+	for i := range msgs {
+		msgs[i] = testSyncMsg(t, string(rune('a'+i)), 10)
+	}
+
+	batches, err := batchSyncMessages(msgs, maxBatchBytes, 2)
+	require.NoError(t, err)
+
+	require.Len(t, batches, 3)
+	assert.Len(t, batches[0].Messages, 2)
+	assert.Len(t, batches[1].Messages, 2)
+	assert.Len(t, batches[2].Messages, 1)
+}
+
+func TestBatchSyncMessagesSplitsOnByteSize(t *testing.T) {
+	one := testSyncMsg(t, "p1", 100)
+	encoded, err := one.ToJSON()
+	require.NoError(t, err)
+
+	msgs := []syncMsg{one, testSyncMsg(t, "p2", 100)}
+
+	// cap just large enough for a single message's JSON, so the second must start a new batch.
+	batches, err := batchSyncMessages(msgs, len(encoded), 100)
+	require.NoError(t, err)
+
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0].Messages, 1)
+	assert.Len(t, batches[1].Messages, 1)
+}
+
+func TestBatchSyncMessagesOversizedMessageGetsOwnBatch(t *testing.T) {
+	small := testSyncMsg(t, "small", 10)
+	huge := testSyncMsg(t, "huge", 10_000)
+
+	msgs := []syncMsg{small, huge, testSyncMsg(t, "after", 10)}
+
+	batches, err := batchSyncMessages(msgs, 100, 100)
+	require.NoError(t, err)
+
+	require.Len(t, batches, 3, "the oversized message must not be dropped or merged with its neighbors")
+	assert.Equal(t, "small", batches[0].Messages[0].PostId)
+	assert.Equal(t, "huge", batches[1].Messages[0].PostId)
+	assert.Equal(t, "after", batches[2].Messages[0].PostId)
+}
+
+func TestBatchSyncMessagesPreservesOrder(t *testing.T) {
+	msgs := []syncMsg{
+		testSyncMsg(t, "p1", 10),
+		testSyncMsg(t, "p2", 10),
+		testSyncMsg(t, "p3", 10),
+	}
+
+	batches, err := batchSyncMessages(msgs, maxBatchBytes, 1)
+	require.NoError(t, err)
+
+	require.Len(t, batches, 3)
+	assert.Equal(t, "p1", batches[0].Messages[0].PostId)
+	assert.Equal(t, "p2", batches[1].Messages[0].PostId)
+	assert.Equal(t, "p3", batches[2].Messages[0].PostId)
+}
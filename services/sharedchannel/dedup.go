@@ -0,0 +1,126 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// dedupCacheSize bounds the number of recent message digests retained per
+// remote cluster before the least-recently-used entry is evicted.
+const dedupCacheSize = 1000
+
+// syncDedupCache is a bounded LRU of recently sent syncMsg digests for a
+// single RemoteCluster. It prevents re-broadcasting a post whose synced
+// content hasn't actually changed, e.g. after a retry, a reaction-only
+// change, or a spurious UpdateAt bump.
+type syncDedupCache struct {
+	mux    sync.Mutex
+	size   int
+	ll     *list.List
+	lookup map[string]*list.Element
+	hits   int64
+	misses int64
+}
+
+func newSyncDedupCache(size int) *syncDedupCache {
+	return &syncDedupCache{
+		size:   size,
+		ll:     list.New(),
+		lookup: make(map[string]*list.Element),
+	}
+}
+
+// seen reports whether digest was already recorded for this remote. When it
+// wasn't, it is added to the cache so a subsequent call returns true.
+func (c *syncDedupCache) seen(digest string) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if el, ok := c.lookup[digest]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return true
+	}
+
+	c.lookup[digest] = c.ll.PushFront(digest)
+	c.misses++
+
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.lookup, oldest.Value.(string))
+	}
+	return false
+}
+
+// Counts returns the hit/miss totals accumulated by this cache so operators
+// can tune dedupCacheSize.
+func (c *syncDedupCache) Counts() (hits int64, misses int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.hits, c.misses
+}
+
+// dedupCacheForRemote returns the syncDedupCache for rc, creating it on
+// first use. One cache is kept per remote cluster for the lifetime of the
+// Service.
+func (scs *Service) dedupCacheForRemote(rc *model.RemoteCluster) *syncDedupCache {
+	scs.dedupMux.Lock()
+	defer scs.dedupMux.Unlock()
+
+	if scs.dedupCaches == nil {
+		scs.dedupCaches = make(map[string]*syncDedupCache)
+	}
+
+	c, ok := scs.dedupCaches[rc.RemoteId]
+	if !ok {
+		c = newSyncDedupCache(dedupCacheSize)
+		scs.dedupCaches[rc.RemoteId] = c
+	}
+	return c
+}
+
+// DedupCounts returns the hit/miss totals for the dedup cache belonging to
+// remoteId, or (0, 0) if no messages have been processed for it yet.
+func (scs *Service) DedupCounts(remoteId string) (hits int64, misses int64) {
+	scs.dedupMux.Lock()
+	c, ok := scs.dedupCaches[remoteId]
+	scs.dedupMux.Unlock()
+	if !ok {
+		return 0, 0
+	}
+	return c.Counts()
+}
+
+// syncMsgDigest produces a stable digest of the parts of sm that determine
+// whether it carries new information for the remote: channel, post, EditAt/
+// DeleteAt, and the reactions riding along with it.
+func syncMsgDigest(sm syncMsg) string {
+	h := sha256.New()
+	h.Write([]byte(sm.ChannelId))
+	h.Write([]byte(sm.PostId))
+
+	if sm.Post != nil {
+		h.Write([]byte(strconv.FormatInt(sm.Post.EditAt, 10)))
+		h.Write([]byte(strconv.FormatInt(sm.Post.DeleteAt, 10)))
+	}
+
+	for _, r := range sm.Reactions {
+		h.Write([]byte(r.UserId))
+		h.Write([]byte(r.EmojiName))
+		h.Write([]byte(strconv.FormatInt(r.DeleteAt, 10)))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
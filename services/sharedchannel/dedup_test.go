@@ -0,0 +1,63 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncDedupCacheSeen(t *testing.T) {
+	c := newSyncDedupCache(2)
+
+	assert.False(t, c.seen("a"), "first sighting of a digest is a miss")
+	assert.True(t, c.seen("a"), "second sighting of the same digest is a hit")
+
+	hits, misses := c.Counts()
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(1), misses)
+}
+
+func TestSyncDedupCacheEviction(t *testing.T) {
+	c := newSyncDedupCache(2)
+
+	require.False(t, c.seen("a"))
+	require.False(t, c.seen("b"))
+	require.False(t, c.seen("c")) // over capacity; evicts "a", the least recently used
+
+	// probe the survivors first: seen() re-inserts a missed digest, so probing "a" first would
+	// evict "b" before it could be checked.
+	assert.True(t, c.seen("b"), "b was touched more recently than a and should still be cached")
+	assert.True(t, c.seen("c"))
+	assert.False(t, c.seen("a"), "evicted entry should be treated as unseen again")
+}
+
+func TestSyncDedupCacheMoveToFrontPreventsEviction(t *testing.T) {
+	c := newSyncDedupCache(2)
+
+	require.False(t, c.seen("a"))
+	require.False(t, c.seen("b"))
+	require.True(t, c.seen("a")) // touch "a" so "b" becomes the least recently used
+	require.False(t, c.seen("c"))
+
+	assert.True(t, c.seen("a"), "a was refreshed and should not have been evicted")
+	assert.False(t, c.seen("b"), "b should have been evicted as the least recently used")
+}
+
+func TestSyncMsgDigest(t *testing.T) {
+	sm := syncMsg{ChannelId: "c1", PostId: "p1", Post: &model.Post{EditAt: 100}}
+
+	assert.Equal(t, syncMsgDigest(sm), syncMsgDigest(sm), "digest must be deterministic")
+
+	other := sm
+	other.PostId = "p2"
+	assert.NotEqual(t, syncMsgDigest(sm), syncMsgDigest(other))
+
+	reactionsAdded := sm
+	reactionsAdded.Reactions = []*model.Reaction{{UserId: "u1", EmojiName: "smile"}}
+	assert.NotEqual(t, syncMsgDigest(sm), syncMsgDigest(reactionsAdded), "a reaction change must change the digest")
+}
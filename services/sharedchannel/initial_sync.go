@@ -0,0 +1,93 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// initialSyncPostLimit bounds how many historical posts are backfilled per
+// page when a remote cluster first attaches to a shared channel, so a slow or
+// low-bandwidth remote receives the channel history a page at a time rather
+// than all at once.
+const initialSyncPostLimit = 1000
+
+// needsInitialSync reports whether rc has not yet completed the initial
+// backfill for channelId. A remote needs it the first time it is invited to
+// the channel, and also if it reconnects with no prior sync recorded.
+func (scs *Service) needsInitialSync(channelId string, rc *model.RemoteCluster) (bool, error) {
+	scr, err := scs.server.GetStore().SharedChannel().GetRemoteByIds(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); ok {
+			return true, nil
+		}
+		return false, err
+	}
+	return !scr.InitialSyncComplete, nil
+}
+
+// backfillPosts pages backward through channelId's history, resuming from the
+// remote's persisted backfill cursor so a crash mid-backfill picks up where
+// it left off rather than restarting. It returns the next page of posts and
+// whether the backfill has reached the channel's oldest post (the page came
+// back shorter than initialSyncPostLimit), in which case the caller should
+// mark the initial sync complete. initialSyncPostLimit bounds each page only;
+// the total number of posts backfilled across pages is not capped.
+func (scs *Service) backfillPosts(channelId string, rc *model.RemoteCluster) ([]*model.Post, bool, error) {
+	scr, err := scs.server.GetStore().SharedChannel().GetRemoteByIds(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); !ok {
+			return nil, false, err
+		}
+		scr = &model.SharedChannelRemote{
+			ChannelId: channelId,
+			RemoteId:  rc.RemoteId,
+		}
+	}
+
+	list, err := scs.server.GetStore().Post().GetPostsBefore(model.GetPostsOptions{
+		ChannelId: channelId,
+		PostId:    scr.BackfillCursor,
+		PerPage:   initialSyncPostLimit,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	posts := make([]*model.Post, 0, len(list.Order))
+	for _, id := range list.Order {
+		posts = append(posts, list.Posts[id])
+	}
+
+	done := len(posts) < initialSyncPostLimit
+	if len(posts) > 0 {
+		// list.Order is newest-first; the last entry is the oldest post in the page and becomes
+		// the cursor for the next (older) page.
+		scr.BackfillCursor = posts[len(posts)-1].Id
+	}
+
+	if _, err = scs.server.GetStore().SharedChannel().UpsertRemote(scr); err != nil {
+		return nil, false, err
+	}
+
+	// the remote must apply backfilled history oldest-first, so reverse the newest-first page
+	// before handing it to postsToSyncMessages.
+	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+		posts[i], posts[j] = posts[j], posts[i]
+	}
+
+	return posts, done, nil
+}
+
+// completeInitialSync persists that rc has finished backfilling channelId's
+// history, so future calls to postsToSyncMessages take the incremental path.
+func (scs *Service) completeInitialSync(channelId string, rc *model.RemoteCluster) error {
+	scr, err := scs.server.GetStore().SharedChannel().GetRemoteByIds(channelId, rc.RemoteId)
+	if err != nil {
+		return err
+	}
+	scr.InitialSyncComplete = true
+	_, err = scs.server.GetStore().SharedChannel().UpsertRemote(scr)
+	return err
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/mlog"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// atMentionPattern matches possible @mention tokens in a post message. It mirrors the pattern the
+// notification pipeline uses (see app's possibleAtMentions) so shared-channel mention handling
+// stays consistent with in-server notifications.
+var atMentionPattern = regexp.MustCompile(`\B@[a-zA-Z0-9][a-zA-Z0-9.\-_]*`)
+
+// specialMentions address a group of users rather than a single local user, so they're left
+// untouched rather than resolved/rewritten for the remote.
+var specialMentions = map[string]bool{
+	"@channel": true,
+	"@all":     true,
+	"@here":    true,
+}
+
+var (
+	fencedCodeBlockPattern = regexp.MustCompile("(?s)```.*?```")
+	inlineCodeSpanPattern  = regexp.MustCompile("`[^`]*`")
+)
+
+// trailingMentionPunct lists characters atMentionPattern's character class allows but that are
+// ordinary sentence punctuation when they end a mention (e.g. "@bob." at the end of a sentence),
+// mirroring how the notification pipeline trims a candidate mention before giving up on it.
+const trailingMentionPunct = ".-_"
+
+// trimTrailingMentionPunct strips trailing punctuation from username, returning the trimmed
+// result and how many characters were removed.
+func trimTrailingMentionPunct(username string) (trimmed string, cut int) {
+	for len(username) > 0 && strings.ContainsRune(trailingMentionPunct, rune(username[len(username)-1])) {
+		username = username[:len(username)-1]
+		cut++
+	}
+	return username, cut
+}
+
+// atMentionMatches returns the [start,end) byte ranges of candidate @mention tokens in message, in
+// left-to-right order, skipping any that fall inside fenced or inline code where an @ is literal
+// text rather than a mention.
+func atMentionMatches(message string) [][]int {
+	if !strings.Contains(message, "@") {
+		return nil
+	}
+
+	var codeSpans [][]int
+	codeSpans = append(codeSpans, fencedCodeBlockPattern.FindAllStringIndex(message, -1)...)
+	codeSpans = append(codeSpans, inlineCodeSpanPattern.FindAllStringIndex(message, -1)...)
+
+	var matches [][]int
+	for _, m := range atMentionPattern.FindAllStringIndex(message, -1) {
+		if inAnyCodeSpan(m[0], codeSpans) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func inAnyCodeSpan(pos int, spans [][]int) bool {
+	for _, s := range spans {
+		if pos >= s[0] && pos < s[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionedUsers resolves @mention tokens in post's message to local users not already present in
+// uCache, feeding each through shouldUserSync/sanitizeUserForSync same as post authors and
+// reactors. It returns post's message with every resolved mention rewritten to a stable,
+// remote-friendly form (@username:remoteid), analogous to how processPermalinkToRemote rewrites
+// permalinks, along with the users to include in the sync message.
+func (scs *Service) mentionedUsers(post *model.Post, rc *model.RemoteCluster, uCache userCache) (string, []*model.User) {
+	matches := atMentionMatches(post.Message)
+	if len(matches) == 0 {
+		return post.Message, nil
+	}
+
+	message := post.Message
+	seen := make(map[string]bool)
+	var users []*model.User
+
+	// substitute by byte span, back to front, so replacing one match never shifts the offsets of
+	// matches still waiting to be processed. A plain strings.ReplaceAll(message, token, ...) would
+	// also corrupt an unrelated mention when one mentioned username is a prefix of another, e.g.
+	// replacing "@john" inside "@johnsmith".
+	for i := len(matches) - 1; i >= 0; i-- {
+		start, end := matches[i][0], matches[i][1]
+		token := message[start:end]
+		if specialMentions[token] {
+			continue
+		}
+
+		username := strings.TrimPrefix(token, "@")
+		usernameEnd := end
+
+		user, err := scs.server.GetStore().User().GetByUsername(username)
+		for err != nil && len(username) > 0 {
+			trimmed, cut := trimTrailingMentionPunct(username)
+			if cut == 0 {
+				break
+			}
+			username = trimmed
+			usernameEnd -= cut
+			user, err = scs.server.GetStore().User().GetByUsername(username)
+		}
+		if err != nil {
+			// not a local user, even after trimming trailing punctuation; leave the token as-is
+			// for the remote to render verbatim.
+			continue
+		}
+
+		message = message[:start] + "@" + username + ":" + rc.RemoteId + message[usernameEnd:]
+
+		if seen[user.Id] || uCache.Has(user.Id) {
+			continue
+		}
+		seen[user.Id] = true
+		uCache.Add(user.Id)
+
+		sync, err := scs.shouldUserSync(user, rc)
+		if err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not find user for mention",
+				mlog.String("user_id", user.Id),
+				mlog.Err(err),
+			)
+			continue
+		}
+		if sync {
+			users = append(users, sanitizeUserForSync(user))
+		}
+	}
+
+	return message, users
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtMentionMatches(t *testing.T) {
+	matchedTokens := func(message string) []string {
+		var tokens []string
+		for _, m := range atMentionMatches(message) {
+			tokens = append(tokens, message[m[0]:m[1]])
+		}
+		return tokens
+	}
+
+	assert.Equal(t, []string{"@bob"}, matchedTokens("hi @bob, how are you"))
+	assert.Equal(t, []string{"@bob", "@alice"}, matchedTokens("@bob and @alice"))
+	assert.Nil(t, matchedTokens("no mentions here"))
+	assert.Equal(t, []string{"@channel"}, matchedTokens("@channel is a special mention"), "specials are matched here; mentionedUsers filters them out")
+}
+
+func TestAtMentionMatchesSkipsCodeSpans(t *testing.T) {
+	assert.Nil(t, atMentionMatches("`@bob`"), "inline code should not be scanned for mentions")
+	assert.Nil(t, atMentionMatches("```\n@bob\n```"), "fenced code should not be scanned for mentions")
+
+	matches := atMentionMatches("@alice says `@bob` is not a mention")
+	assert.Len(t, matches, 1)
+}
+
+func TestTrimTrailingMentionPunct(t *testing.T) {
+	trimmed, cut := trimTrailingMentionPunct("bob.")
+	assert.Equal(t, "bob", trimmed)
+	assert.Equal(t, 1, cut)
+
+	trimmed, cut = trimTrailingMentionPunct("bob")
+	assert.Equal(t, "bob", trimmed)
+	assert.Equal(t, 0, cut)
+
+	trimmed, cut = trimTrailingMentionPunct("bob...")
+	assert.Equal(t, "bob", trimmed)
+	assert.Equal(t, 3, cut)
+}
@@ -51,7 +51,51 @@ func (u userCache) Add(id string) {
 
 // postsToSyncMessages takes a slice of posts and converts to a `RemoteClusterMsg` which can be
 // sent to a remote cluster.
-func (scs *Service) postsToSyncMessages(posts []*model.Post, rc *model.RemoteCluster, nextSyncAt int64) ([]syncMsg, error) {
+//
+// If rc has not yet completed its initial sync for channelId, posts is ignored in favor of a
+// backfilled page of channel history; the incremental path implemented below only runs once that
+// backfill is complete.
+func (scs *Service) postsToSyncMessages(channelId string, posts []*model.Post, rc *model.RemoteCluster, nextSyncAt int64) ([]syncMsg, error) {
+	isBackfill := false
+
+	if needs, err := scs.needsInitialSync(channelId, rc); err != nil {
+		return nil, err
+	} else if needs {
+		backfill, done, err := scs.backfillPosts(channelId, rc)
+		if err != nil {
+			return nil, err
+		}
+		posts = backfill
+		isBackfill = true
+
+		if done {
+			defer func() {
+				if err := scs.completeInitialSync(channelId, rc); err != nil {
+					scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not mark initial sync complete",
+						mlog.String("channel_id", channelId),
+						mlog.String("remote_id", rc.RemoteId),
+						mlog.Err(err),
+					)
+				}
+			}()
+		}
+	}
+
+	// The "don't resend reaction-only changes" suppression below, and the retry floor, both rely
+	// on posts having been fetched by nextSyncAt (the incremental path's invariant). Backfilled
+	// posts come from GetPostsBefore instead and have nothing to do with nextSyncAt, which keeps
+	// advancing every sync tick; reusing that logic for them would silently drop every backfill
+	// page after the first. So neither applies while isBackfill is true.
+	var retryFloor int64
+	var retryPending bool
+	if !isBackfill {
+		var err error
+		retryFloor, retryPending, err = scs.retryFloor(channelId, rc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	syncMessages := make([]syncMsg, 0, len(posts))
 
 	uCache := make(userCache)
@@ -61,8 +105,20 @@ func (scs *Service) postsToSyncMessages(posts []*model.Post, rc *model.RemoteClu
 			continue
 		}
 
-		// any reactions originating from the remote cluster are filtered out
-		reactions, err := scs.server.GetStore().Reaction().GetForPostSince(p.Id, nextSyncAt, rc.RemoteId, true)
+		// posts covered by an outstanding retry are owned by the retry path; skip them here so
+		// they aren't delivered twice, out of order, once the retry succeeds.
+		if retryCoalesces(retryPending, retryFloor, p.EditAt, p.CreateAt) {
+			continue
+		}
+
+		// any reactions originating from the remote cluster are filtered out. During backfill the
+		// remote has never seen this post, so its whole reaction history is pulled; incrementally,
+		// only reactions added since nextSyncAt are new.
+		reactionsSince := nextSyncAt
+		if isBackfill {
+			reactionsSince = 0
+		}
+		reactions, err := scs.server.GetStore().Reaction().GetForPostSince(p.Id, reactionsSince, rc.RemoteId, true)
 		if err != nil {
 			return nil, err
 		}
@@ -74,7 +130,9 @@ func (scs *Service) postsToSyncMessages(posts []*model.Post, rc *model.RemoteClu
 		//   - new posts (EditAt == 0)
 		//   - edited posts (EditAt >= nextSyncAt)
 		//   - deleted posts (DeleteAt > 0)
-		if p.EditAt > 0 && p.EditAt < nextSyncAt && p.DeleteAt == 0 {
+		// Backfilled posts are always sent in full: the remote has no prior copy of them to diff
+		// against, so this suppression (which is keyed on nextSyncAt) doesn't apply.
+		if !isBackfill && p.EditAt > 0 && p.EditAt < nextSyncAt && p.DeleteAt == 0 {
 			postSync = nil
 		}
 
@@ -98,6 +156,20 @@ func (scs *Service) postsToSyncMessages(posts []*model.Post, rc *model.RemoteClu
 			}
 		}
 
+		// skip messages we've already sent to this remote with no real change. This must happen
+		// before usersForPost resolves the post's users: if we checked after, a dropped duplicate
+		// would still mark its users "seen" in uCache, and those users would then be silently
+		// skipped if they next appear on a message that does get sent.
+		digest := syncMsgDigest(syncMsg{
+			ChannelId: p.ChannelId,
+			PostId:    p.Id,
+			Post:      postSync,
+			Reactions: reactions,
+		})
+		if scs.dedupCacheForRemote(rc).seen(digest) {
+			continue
+		}
+
 		// any users originating from the remote cluster are filtered out
 		users := scs.usersForPost(postSync, reactions, rc, uCache)
 
@@ -114,6 +186,7 @@ func (scs *Service) postsToSyncMessages(posts []*model.Post, rc *model.RemoteClu
 			Reactions:   reactions,
 			Attachments: attachments,
 		}
+
 		syncMessages = append(syncMessages, sm)
 	}
 	return syncMessages, nil
@@ -137,8 +210,6 @@ func (scs *Service) usersForPost(post *model.Post, reactions []*model.Reaction,
 		}
 	}
 
-	// TODO: extract @mentions to local users and sync those as well?
-
 	users := make([]*model.User, 0)
 
 	for _, id := range userIds {
@@ -158,6 +229,15 @@ func (scs *Service) usersForPost(post *model.Post, reactions []*model.Reaction,
 				mlog.Err(err))
 		}
 	}
+
+	// extract @mentions to local users, rewriting them in the post for the remote, and sync
+	// those users as well.
+	if post != nil {
+		rewritten, mentioned := scs.mentionedUsers(post, rc, uCache)
+		post.Message = rewritten
+		users = append(users, mentioned...)
+	}
+
 	return users
 }
 
@@ -208,4 +288,4 @@ func (scs *Service) shouldUserSync(user *model.User, rc *model.RemoteCluster) (b
 		return false, nil
 	}
 	return true, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/mlog"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	// retryMaxAttempts caps how many times a failed batch is retried before it
+	// is parked and reported via a structured log event.
+	retryMaxAttempts = 8
+
+	// retryBaseDelay and retryMaxDelay bound the exponential backoff applied
+	// between attempts.
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// retryBackoffCeiling returns the backoff ceiling for attempt, before jitter is applied: the base
+// delay doubled once per attempt, capped at retryMaxDelay.
+func retryBackoffCeiling(attempt int) time.Duration {
+	d := retryBaseDelay
+	for i := 0; i < attempt && d < retryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d
+}
+
+// retryBackoff returns the delay before the next attempt: retryBackoffCeiling(attempt) with up to
+// 50% jitter, to avoid thundering-herd retries against a remote that's recovering.
+func retryBackoff(attempt int) time.Duration {
+	ceiling := retryBackoffCeiling(attempt)
+	return ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)+1))
+}
+
+// retryCoalesces reports whether a post is owned by a pending retry and should be skipped by the
+// incremental sync path rather than re-emitted: it belongs to the batch already queued for retry.
+func retryCoalesces(retryPending bool, retryFloor int64, editAt int64, createAt int64) bool {
+	return retryPending && editAt < retryFloor && createAt < retryFloor
+}
+
+// scheduleRetry persists a failed sync batch for (channelId, rc) so it can be
+// redelivered with exponential backoff. nextSyncAt is the cursor that was in
+// effect when the batch was built; posts at or after it are untouched by the
+// failure and remain eligible for the normal incremental path. Once
+// retryMaxAttempts is exceeded the item is parked: it stops blocking forward
+// sync progress, but the record is kept (and a structured log event raised)
+// so an operator can find and resend it.
+func (scs *Service) scheduleRetry(channelId string, rc *model.RemoteCluster, nextSyncAt int64, cause error) error {
+	retry, err := scs.server.GetStore().SharedChannelSyncRetry().Get(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); !ok {
+			return err
+		}
+		retry = &model.SharedChannelSyncRetry{
+			ChannelId: channelId,
+			RemoteId:  rc.RemoteId,
+		}
+	}
+
+	retry.NextSyncAt = nextSyncAt
+	retry.Attempt++
+	retry.NextAttemptAt = model.GetMillis() + retryBackoff(retry.Attempt).Milliseconds()
+
+	if retry.Attempt > retryMaxAttempts {
+		retry.Parked = true
+		scs.untrackPendingRetry(channelId, rc.RemoteId)
+		scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Parking shared channel sync batch after repeated failures",
+			mlog.String("channel_id", channelId),
+			mlog.String("remote_id", rc.RemoteId),
+			mlog.Int("attempt", retry.Attempt),
+			mlog.Err(cause),
+		)
+	} else {
+		scs.trackPendingRetry(channelId, rc.RemoteId)
+	}
+
+	_, err = scs.server.GetStore().SharedChannelSyncRetry().Upsert(retry)
+	return err
+}
+
+// clearRetry removes any pending retry for (channelId, rc), called once a
+// retried batch is successfully delivered.
+func (scs *Service) clearRetry(channelId string, rc *model.RemoteCluster) error {
+	scs.untrackPendingRetry(channelId, rc.RemoteId)
+
+	err := scs.server.GetStore().SharedChannelSyncRetry().Delete(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// retryKey identifies one outstanding retry by the (channel, remote) pair it's queued against.
+type retryKey struct {
+	channelId string
+	remoteId  string
+}
+
+// trackPendingRetry records that (channelId, remoteId) has an outstanding, not-yet-parked retry,
+// so retryLoop knows to poll it.
+func (scs *Service) trackPendingRetry(channelId string, remoteId string) {
+	scs.pendingRetryMux.Lock()
+	defer scs.pendingRetryMux.Unlock()
+	if scs.pendingRetries == nil {
+		scs.pendingRetries = make(map[retryKey]bool)
+	}
+	scs.pendingRetries[retryKey{channelId, remoteId}] = true
+}
+
+// untrackPendingRetry removes (channelId, remoteId) from the set retryLoop polls, called once a
+// retry is cleared or parked.
+func (scs *Service) untrackPendingRetry(channelId string, remoteId string) {
+	scs.pendingRetryMux.Lock()
+	defer scs.pendingRetryMux.Unlock()
+	delete(scs.pendingRetries, retryKey{channelId, remoteId})
+}
+
+// pendingRetryKeys returns a snapshot of the (channel, remote) pairs with an outstanding retry.
+func (scs *Service) pendingRetryKeys() []retryKey {
+	scs.pendingRetryMux.Lock()
+	defer scs.pendingRetryMux.Unlock()
+	keys := make([]retryKey, 0, len(scs.pendingRetries))
+	for k := range scs.pendingRetries {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// retryFloor reports the nextSyncAt cursor of an outstanding, not-yet-parked
+// retry for (channelId, rc), if any. The incremental sync path skips posts
+// older than this cursor since they belong to the batch already queued for
+// retry; re-emitting them would deliver duplicates out of order once the
+// retry succeeds.
+func (scs *Service) retryFloor(channelId string, rc *model.RemoteCluster) (floor int64, pending bool, err error) {
+	retry, err := scs.server.GetStore().SharedChannelSyncRetry().Get(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); ok {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if retry.Parked {
+		return 0, false, nil
+	}
+	return retry.NextSyncAt, true, nil
+}
+
+// retryDue reports whether enough time has passed to attempt the pending
+// retry for (channelId, rc).
+func (scs *Service) retryDue(channelId string, rc *model.RemoteCluster) (bool, error) {
+	retry, err := scs.server.GetStore().SharedChannelSyncRetry().Get(channelId, rc.RemoteId)
+	if err != nil {
+		if _, ok := err.(errNotFound); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return !retry.Parked && model.GetMillis() >= retry.NextAttemptAt, nil
+}
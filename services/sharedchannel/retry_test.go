@@ -0,0 +1,35 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoffCeiling(t *testing.T) {
+	assert.Equal(t, retryBaseDelay, retryBackoffCeiling(0))
+	assert.Equal(t, retryBaseDelay*2, retryBackoffCeiling(1))
+	assert.Equal(t, retryBaseDelay*4, retryBackoffCeiling(2))
+	assert.Equal(t, retryMaxDelay, retryBackoffCeiling(30), "should saturate at retryMaxDelay rather than overflow")
+}
+
+func TestRetryBackoffWithinCeiling(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		ceiling := retryBackoffCeiling(attempt)
+		for i := 0; i < 50; i++ {
+			d := retryBackoff(attempt)
+			assert.GreaterOrEqual(t, d, ceiling/2)
+			assert.LessOrEqual(t, d, ceiling)
+		}
+	}
+}
+
+func TestRetryCoalesces(t *testing.T) {
+	assert.False(t, retryCoalesces(false, 1000, 0, 500), "no pending retry means nothing is coalesced")
+	assert.True(t, retryCoalesces(true, 1000, 500, 500), "post older than the retry floor belongs to the pending retry")
+	assert.False(t, retryCoalesces(true, 1000, 1500, 500), "an edited post past the floor is new and must still sync")
+	assert.False(t, retryCoalesces(true, 1000, 500, 1500), "a new post past the floor is new and must still sync")
+}
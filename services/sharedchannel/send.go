@@ -0,0 +1,136 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sharedchannel
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/mlog"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// syncTopic is the RemoteClusterMsg topic used for shared channel sync messages.
+const syncTopic = "sharedchannel_sync"
+
+// retryPollInterval controls how often retryLoop wakes up to look for outstanding retries whose
+// backoff has elapsed.
+const retryPollInterval = 5 * time.Second
+
+// sendSyncMessages converts posts to sync messages for rc, packs them into size-capped, optionally
+// compressed batch envelopes, and sends each envelope through the remote cluster service. It
+// drives the retry subsystem: a delivery failure schedules a retry, a success clears any retry
+// that was pending for (channelId, rc).
+func (scs *Service) sendSyncMessages(channelId string, posts []*model.Post, rc *model.RemoteCluster, nextSyncAt int64) error {
+	msgs, err := scs.postsToSyncMessages(channelId, posts, rc, nextSyncAt)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	envelopes, err := scs.batchesForRemote(msgs, rc)
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range envelopes {
+		sendErr := scs.server.GetRemoteClusterService().SendMsg(rc, model.RemoteClusterMsg{
+			Topic:   syncTopic,
+			Payload: payload,
+		})
+		if sendErr != nil {
+			if err := scs.scheduleRetry(channelId, rc, nextSyncAt, sendErr); err != nil {
+				scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not schedule shared channel sync retry",
+					mlog.String("channel_id", channelId),
+					mlog.String("remote_id", rc.RemoteId),
+					mlog.Err(err),
+				)
+			}
+			return sendErr
+		}
+	}
+
+	if err := scs.clearRetry(channelId, rc); err != nil {
+		scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not clear shared channel sync retry",
+			mlog.String("channel_id", channelId),
+			mlog.String("remote_id", rc.RemoteId),
+			mlog.Err(err),
+		)
+	}
+
+	return nil
+}
+
+// retryLoop periodically reattempts outstanding shared channel sync retries that have become due,
+// until stopped is closed. One instance runs for the life of the Service.
+func (scs *Service) retryLoop(stopped <-chan struct{}) {
+	ticker := time.NewTicker(retryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			scs.retryDueBatches()
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// retryDueBatches reattempts every tracked retry whose backoff has elapsed, refetching posts from
+// its persisted cursor and replaying them through sendSyncMessages.
+func (scs *Service) retryDueBatches() {
+	for _, key := range scs.pendingRetryKeys() {
+		rc, err := scs.server.GetStore().RemoteCluster().Get(key.remoteId)
+		if err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not load remote cluster for shared channel sync retry",
+				mlog.String("remote_id", key.remoteId),
+				mlog.Err(err),
+			)
+			continue
+		}
+
+		due, err := scs.retryDue(key.channelId, rc)
+		if err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not check shared channel sync retry due time",
+				mlog.String("channel_id", key.channelId),
+				mlog.String("remote_id", key.remoteId),
+				mlog.Err(err),
+			)
+			continue
+		}
+		if !due {
+			continue
+		}
+
+		retry, err := scs.server.GetStore().SharedChannelSyncRetry().Get(key.channelId, key.remoteId)
+		if err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not load shared channel sync retry",
+				mlog.String("channel_id", key.channelId),
+				mlog.String("remote_id", key.remoteId),
+				mlog.Err(err),
+			)
+			continue
+		}
+
+		posts, err := scs.server.GetStore().Post().GetPostsSince(key.channelId, retry.NextSyncAt)
+		if err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Could not fetch posts for shared channel sync retry",
+				mlog.String("channel_id", key.channelId),
+				mlog.String("remote_id", key.remoteId),
+				mlog.Err(err),
+			)
+			continue
+		}
+
+		if err := scs.sendSyncMessages(key.channelId, posts, rc, retry.NextSyncAt); err != nil {
+			scs.server.GetLogger().Log(mlog.LvlSharedChannelServiceError, "Shared channel sync retry failed",
+				mlog.String("channel_id", key.channelId),
+				mlog.String("remote_id", key.remoteId),
+				mlog.Err(err),
+			)
+		}
+	}
+}